@@ -0,0 +1,126 @@
+package xjson
+
+// This file turns the loader generic over the underlying file
+// format: [RegisterFormat] plugs in a decoder/encoder pair for a
+// given extension, and [Read]/[Write]/[GetPaths]/[TrimExt] dispatch
+// on it instead of assuming every file is JSON. Only ".json" is
+// registered by this package; [Read]/[Write] only need to import
+// a sibling adapter package (e.g. xjson/yaml) to gain support for
+// another format, paying for its dependencies only then.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Decoder unmarshals a file's raw bytes into a value that [Read]
+// stores as-is. The ".json" decoder yields map[string]any, []any,
+// string, float64, bool or nil; other decoders may yield other
+// types (e.g. int for a YAML integer).
+type Decoder func([]byte) (any, error)
+
+// Encoder is the inverse of a [Decoder].
+type Encoder func(any) ([]byte, error)
+
+type format struct {
+	decode Decoder
+	encode Encoder
+}
+
+var formats = map[string]format{}
+
+// RegisterFormat registers decode/encode for ext (with its leading
+// dot, e.g. ".yaml"), so [Read], [Write] and friends dispatch to
+// them for files bearing that extension. encode may be nil for a
+// format [Write] can't produce. Registering an already-registered
+// ext overrides it.
+func RegisterFormat(ext string, decode Decoder, encode Encoder) {
+	formats[ext] = format{decode, encode}
+}
+
+// IsRegisteredFormat reports whether ext (with its leading dot) has
+// been registered via [RegisterFormat].
+func IsRegisteredFormat(ext string) bool {
+	_, ok := formats[ext]
+	return ok
+}
+
+// RegisteredExts returns every registered extension, sorted for
+// deterministic iteration (e.g. by [DoReadAndStoreFS] when looking
+// for "foo.<ext>" candidates).
+func RegisteredExts() []string {
+	xs := make([]string, 0, len(formats))
+	for ext := range formats {
+		xs = append(xs, ext)
+	}
+	sort.Strings(xs)
+	return xs
+}
+
+func decoderFor(ext string) (Decoder, bool) {
+	f, ok := formats[ext]
+	if !ok || f.decode == nil {
+		return nil, false
+	}
+	return f.decode, true
+}
+
+func encoderFor(ext string) (Encoder, bool) {
+	f, ok := formats[ext]
+	if !ok || f.encode == nil {
+		return nil, false
+	}
+	return f.encode, true
+}
+
+// ExtMap records, for a dotted path (see [PathString]), the file
+// extension the leaf at that path was read with, e.g.
+// "foo.bar" -> ".yaml". [StoreExt] (via [WithExtMap]) populates it
+// on read; [WriteFS] (via the write-side [UseExtMap]) consults it
+// to write a leaf back out under its original format.
+type ExtMap map[string]string
+
+// extFor looks up kp in em, defaulting to [Ext] if em is nil or has
+// no entry for kp.
+func extFor(kp []string, em ExtMap) string {
+	if em != nil {
+		if ext, ok := em[PathString(kp)]; ok {
+			return ext
+		}
+	}
+	return Ext
+}
+
+func init() {
+	RegisterFormat(Ext, decodeJSON, encodeJSON)
+}
+
+// decodeJSON is also used as the fallback decoder for files whose
+// extension isn't registered, preserving the package's original
+// JSON-only behaviour for direct [ReadFile] calls.
+func decodeJSON(bs []byte) (any, error) {
+	var v any
+	err := json.Unmarshal(bs, &v)
+
+	// error − if any − is expected to be a json.SyntaxError,
+	// which contains an offset, from which we can compute line
+	// and column numbers.
+	var jErr *json.SyntaxError
+	if errors.As(err, &jErr) {
+		off := jErr.Offset
+		// https://github.com/golang/go/issues/43513#issuecomment-755754498
+		line := 1 + bytes.Count(bs[:off], []byte("\n"))
+		col := int64(1) + off - int64(bytes.LastIndex(bs[:off], []byte("\n"))+len("\n"))
+
+		return v, fmt.Errorf("%d:%d %w", line, col, err)
+	}
+
+	return v, err
+}
+
+func encodeJSON(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
@@ -0,0 +1,76 @@
+package xjson
+
+import (
+	"testing"
+
+	"github.com/mbivert/ftests"
+)
+
+// RegisterFormatT wraps RegisterFormat to ease tests: it registers
+// .fake, then reports back the three things a test cares about.
+func RegisterFormatT() (bool, bool, bool) {
+	decode := func(bs []byte) (any, error) { return string(bs), nil }
+	encode := func(v any) ([]byte, error) { return []byte(v.(string)), nil }
+
+	RegisterFormat(".fake", decode, encode)
+	defer delete(formats, ".fake")
+
+	found := false
+	for _, ext := range RegisteredExts() {
+		if ext == ".fake" {
+			found = true
+		}
+	}
+
+	return IsRegisteredFormat(".fake"), IsRegisteredFormat(".nope"), found
+}
+
+func TestRegisterFormat(t *testing.T) {
+	ftests.Run(t, []ftests.Test{
+		{
+			Name:     "fake_format",
+			Fun:      RegisterFormatT,
+			Args:     []any{},
+			Expected: []any{true, false, true},
+		},
+	})
+}
+
+func TestTrimExtUnregistered(t *testing.T) {
+	ftests.Run(t, []ftests.Test{
+		{
+			Name:     "unregistered_extension_left_untouched",
+			Fun:      TrimExt,
+			Args:     []any{"foo.bar"},
+			Expected: []any{"foo.bar"},
+		},
+		{
+			Name:     "registered_extension_trimmed",
+			Fun:      TrimExt,
+			Args:     []any{"foo.json"},
+			Expected: []any{"foo"},
+		},
+	})
+}
+
+// "foo.<ext>" is only a valid sibling of "foo/" when <ext> is a
+// registered format; anything else (e.g. a README.md dropped in the
+// tree) is ignored by DoReadAndStoreFSF.
+func TestReadFSUnregisteredSibling(t *testing.T) {
+	mfs := NewMemFS(map[string]string{
+		"db/README.md": "# not config",
+		"db/foo.json":  `"bar"`,
+	})
+
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "unregistered_sibling_ignored",
+			Fun:  ReadFS,
+			Args: []any{mfs, "db"},
+			Expected: []any{
+				map[string]any{"foo": "bar"},
+				nil,
+			},
+		},
+	})
+}
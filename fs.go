@@ -0,0 +1,275 @@
+package xjson
+
+// This file provides the filesystem abstraction backing [Read] and
+// [Write]: [OSFS] wraps the os package (the historical behaviour of
+// this package), and [MemFS] is a small in-memory filesystem, handy
+// for tests or for embedding a JSON-directory without touching disk.
+//
+// The read side only needs fs.FS: [ReadFS] gets there through
+// fs.ReadFile and fs.WalkDir, both of which work against any fs.FS.
+// The write side needs a bit more (creating directories, writing
+// files), hence [WritableFS].
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WritableFS is the filesystem abstraction used by [WriteFS]: an
+// fs.FS that can also create directories and write files, so a
+// map[string]any can be materialized onto it.
+type WritableFS interface {
+	fs.FS
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+}
+
+// OSFS is the default [WritableFS]: it goes through the os package
+// directly rather than os.DirFS, so absolute paths, "..", etc. keep
+// working exactly as they did before Read & friends were taught to
+// operate against an interface.
+type OSFS struct{}
+
+func (OSFS) Open(name string) (fs.File, error) { return os.Open(name) }
+
+func (OSFS) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+
+func (OSFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+
+func (OSFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+func (OSFS) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+// MemFS is a minimal in-memory [WritableFS], seeded from a map of
+// path to file content. Paths are slash-separated and relative, as
+// for any fs.FS (e.g. "foo/bar.json"). It exists so tests (and
+// callers plugging in embedded or remotely-fetched data) can drive
+// [ReadFS] / [WriteFS] without touching a real filesystem.
+type MemFS struct {
+	mu   sync.RWMutex
+	tree map[string]any // map[string]any node == directory; []byte leaf == file
+}
+
+// NewMemFS builds a MemFS from path -> content pairs.
+func NewMemFS(files map[string]string) *MemFS {
+	m := &MemFS{tree: make(map[string]any)}
+	for k, v := range files {
+		m.addLocked(k, []byte(v))
+	}
+	return m
+}
+
+func (m *MemFS) addLocked(name string, data []byte) {
+	xs := strings.Split(strings.Trim(name, "/"), "/")
+
+	p := m.tree
+	for i, x := range xs {
+		if i == len(xs)-1 {
+			p[x] = data
+			return
+		}
+		q, ok := p[x].(map[string]any)
+		if !ok {
+			q = make(map[string]any)
+			p[x] = q
+		}
+		p = q
+	}
+}
+
+func (m *MemFS) lookupLocked(name string) (any, bool) {
+	if name == "." {
+		return m.tree, true
+	}
+
+	var p any = m.tree
+	for _, x := range strings.Split(name, "/") {
+		mp, ok := p.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		p, ok = mp[x]
+		if !ok {
+			return nil, false
+		}
+	}
+	return p, true
+}
+
+func (m *MemFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	v, ok := m.lookupLocked(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	base := name
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		base = name[i+1:]
+	}
+
+	switch x := v.(type) {
+	case []byte:
+		return &memFile{Reader: bytes.NewReader(x), info: memFileInfo{name: base, size: int64(len(x))}}, nil
+	case map[string]any:
+		return &memDir{info: memFileInfo{name: base, dir: true}, node: x}, nil
+	default:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	f, err := m.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := m.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d, ok := f.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return d.ReadDir(-1)
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.addLocked(name, append([]byte(nil), data...))
+	return nil
+}
+
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = strings.Trim(name, "/")
+	if name == "" {
+		return nil
+	}
+
+	p := m.tree
+	for _, x := range strings.Split(name, "/") {
+		q, ok := p[x].(map[string]any)
+		if !ok {
+			if _, exists := p[x]; exists {
+				return &fs.PathError{Op: "mkdir", Path: name, Err: fmt.Errorf("not a directory")}
+			}
+			q = make(map[string]any)
+			p[x] = q
+		}
+		p = q
+	}
+	return nil
+}
+
+// memFileInfo is shared by memFile and memDir / memDirEntry.
+type memFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (fi memFileInfo) Name() string { return fi.name }
+func (fi memFileInfo) Size() int64  { return fi.size }
+func (fi memFileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0750
+	}
+	return 0640
+}
+func (fi memFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi memFileInfo) IsDir() bool        { return fi.dir }
+func (fi memFileInfo) Sys() any           { return nil }
+
+type memDirEntry struct{ info memFileInfo }
+
+func (e memDirEntry) Name() string               { return e.info.name }
+func (e memDirEntry) IsDir() bool                { return e.info.dir }
+func (e memDirEntry) Type() fs.FileMode          { return e.info.Mode().Type() }
+func (e memDirEntry) Info() (fs.FileInfo, error) { return e.info, nil }
+
+type memFile struct {
+	*bytes.Reader
+	info memFileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+type memDir struct {
+	info    memFileInfo
+	node    map[string]any
+	entries []fs.DirEntry
+	off     int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memDir) Close() error               { return nil }
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fmt.Errorf("is a directory")}
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.entries == nil {
+		names := make([]string, 0, len(d.node))
+		for k := range d.node {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+
+		for _, k := range names {
+			switch x := d.node[k].(type) {
+			case map[string]any:
+				d.entries = append(d.entries, memDirEntry{memFileInfo{name: k, dir: true}})
+			case []byte:
+				d.entries = append(d.entries, memDirEntry{memFileInfo{name: k, size: int64(len(x))}})
+			}
+		}
+	}
+
+	if n <= 0 {
+		es := d.entries[d.off:]
+		d.off = len(d.entries)
+		return es, nil
+	}
+
+	if d.off >= len(d.entries) {
+		return nil, io.EOF
+	}
+
+	end := d.off + n
+	if end > len(d.entries) {
+		end = len(d.entries)
+	}
+	es := d.entries[d.off:end]
+	d.off = end
+	return es, nil
+}
@@ -0,0 +1,69 @@
+package xjson
+
+import (
+	"testing"
+
+	"github.com/mbivert/ftests"
+)
+
+func TestReadFS(t *testing.T) {
+	mfs := NewMemFS(map[string]string{
+		"db/foo.json":         `{"hello":"world"}`,
+		"db/foo/bar/baz.json": `"foo"`,
+	})
+
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "mem_db",
+			Fun:  ReadFS,
+			Args: []any{mfs, "db"},
+			Expected: []any{
+				map[string]any{
+					"foo": map[string]any{
+						"hello": "world",
+						"bar": map[string]any{
+							"baz": "foo",
+						},
+					},
+				},
+				nil,
+			},
+		},
+	})
+}
+
+// WriteFST wraps WriteFS/ReadFS to ease tests: it writes db out,
+// then reads it back, so the test can assert on the round-trip.
+func WriteFST(wfs WritableFS, path string, db map[string]any) (map[string]any, error) {
+	if err := WriteFS(wfs, path, db); err != nil {
+		return nil, err
+	}
+	return ReadFS(wfs, path)
+}
+
+func TestWriteFS(t *testing.T) {
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "mem_db",
+			Fun:  WriteFST,
+			Args: []any{
+				NewMemFS(nil), "db",
+				map[string]any{
+					"foo": "bar",
+					"baz": map[string]any{
+						"qux": "quux",
+					},
+				},
+			},
+			Expected: []any{
+				map[string]any{
+					"foo": "bar",
+					"baz": map[string]any{
+						"qux": "quux",
+					},
+				},
+				nil,
+			},
+		},
+	})
+}
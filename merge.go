@@ -0,0 +1,100 @@
+package xjson
+
+// This file backs the [DeepMergeMaps]/[AppendArraysAny]/
+// [UniqueAppend] [SetFlags]: recursive map merging and array
+// merging, shared by [SetF] and [Store].
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// deepMergeMaps merges src into dst in place, recursing into nested
+// map[string]any values instead of stopping at the first level like
+// [MergeMaps] does. flags controls how array values and leaf
+// conflicts are handled; see [DeepMergePreferOld] and
+// [DeepMergeError].
+func deepMergeMaps(dst, src map[string]any, flags SetFlags) error {
+	for k, sv := range src {
+		dv, exists := dst[k]
+		if !exists || reflect.DeepEqual(dv, sv) {
+			dst[k] = sv
+			continue
+		}
+
+		if sm, ok := sv.(map[string]any); ok {
+			if dm, ok := dv.(map[string]any); ok {
+				if err := deepMergeMaps(dm, sm, flags); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if flags&AppendArraysAny == AppendArraysAny {
+			if sa, ok := sv.([]any); ok {
+				if da, ok := dv.([]any); ok {
+					dst[k] = appendUnique(da, sa, flags)
+					continue
+				}
+			}
+		}
+
+		switch {
+		case flags&DeepMergeError == DeepMergeError:
+			return fmt.Errorf("%w: %s", ErrConflict, k)
+		case flags&DeepMergePreferOld == DeepMergePreferOld:
+			// keep dst[k] as-is
+		default:
+			dst[k] = sv
+		}
+	}
+
+	return nil
+}
+
+// appendUnique appends w to q, skipping (under [UniqueAppend])
+// values already present in q per reflect.DeepEqual.
+func appendUnique(q, w []any, flags SetFlags) []any {
+	if flags&UniqueAppend != UniqueAppend {
+		return append(q, w...)
+	}
+
+	for _, v := range w {
+		if !containsAny(q, v) {
+			q = append(q, v)
+		}
+	}
+	return q
+}
+
+func containsAny(xs []any, v any) bool {
+	for _, x := range xs {
+		if reflect.DeepEqual(x, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendUniqueT is [appendUnique] for the generic []T path
+// [AppendArrays] takes.
+func appendUniqueT[T any](q, w []T, flags SetFlags) []T {
+	if flags&UniqueAppend != UniqueAppend {
+		return append(q, w...)
+	}
+
+	for _, v := range w {
+		dup := false
+		for _, x := range q {
+			if reflect.DeepEqual(x, v) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			q = append(q, v)
+		}
+	}
+	return q
+}
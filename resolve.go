@@ -0,0 +1,274 @@
+package xjson
+
+// This file implements a post-load pass, [Resolve], which expands
+// `$include`/`$ref` directives found in a tree produced by [Read]:
+// a node shaped as `{"$include": "some/path"}` (or `{"$ref": ...}`)
+// is replaced by the value found at that path. Four forms of
+// reference are supported:
+//
+//   - an absolute in-DB reference, dotted:       "foo.bar.baz"
+//   - a relative file reference:                 "./sibling.json"
+//   - a file reference with a JSON-Pointer frag: "file.json#/a/b"
+//   - an in-document JSON-Pointer fragment:      "#/a/b"
+//
+// It can optionally be hooked as the final step of [Read]/[ReadFS]
+// via [WithResolve].
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var ErrCycle = errors.New("cycle detected")
+
+// Rule is a user-supplied transform run on every node [Resolve]
+// visits (after directives on that node, if any, have themselves
+// been resolved), e.g. env-var expansion, defaults, redaction.
+type Rule func(path []string, node any) any
+
+type resolveConfig struct {
+	fsys  fs.FS
+	rules []Rule
+	dir   string
+}
+
+// ResolveOpt configures [Resolve].
+type ResolveOpt func(*resolveConfig)
+
+// WithFS sets the filesystem file references are loaded from.
+// Defaults to [OSFS].
+func WithFS(fsys fs.FS) ResolveOpt {
+	return func(c *resolveConfig) { c.fsys = fsys }
+}
+
+// WithRules appends rules run on every node as [Resolve] walks db.
+func WithRules(rules ...Rule) ResolveOpt {
+	return func(c *resolveConfig) { c.rules = append(c.rules, rules...) }
+}
+
+// WithBaseDir sets the directory a relative file reference (e.g.
+// "./sibling.json" or "sibling.json") is joined onto before being
+// read off fsys. Defaults to "", i.e. the root of fsys. [Read]/
+// [ReadFS] set this automatically to the directory db was loaded
+// from, so [WithResolve] users don't need to pass it themselves.
+func WithBaseDir(dir string) ResolveOpt {
+	return func(c *resolveConfig) { c.dir = dir }
+}
+
+// Resolve walks db in place, expanding `$include`/`$ref` directives
+// and applying any rules given via [WithRules] to every node.
+func Resolve(db map[string]any, opts ...ResolveOpt) error {
+	c := resolveConfig{fsys: OSFS{}}
+	for _, o := range opts {
+		o(&c)
+	}
+
+	r := &resolver{
+		root:      db,
+		fsys:      c.fsys,
+		rules:     c.rules,
+		dir:       c.dir,
+		resolving: make(map[string]bool),
+	}
+
+	for k, v := range db {
+		nv, err := r.resolveNode(concat(nil, k), v)
+		if err != nil {
+			return err
+		}
+		db[k] = nv
+	}
+
+	return nil
+}
+
+type resolver struct {
+	root      map[string]any
+	fsys      fs.FS
+	rules     []Rule
+	dir       string
+	resolving map[string]bool
+}
+
+func (r *resolver) resolveNode(path []string, node any) (any, error) {
+	switch x := node.(type) {
+	case map[string]any:
+		if ref, ok := includeRef(x); ok {
+			// resolveRef runs resolveNode on the loaded value, which
+			// already applies the rules to it
+			return r.resolveRef(path, ref)
+		}
+
+		for k, v := range x {
+			nv, err := r.resolveNode(concat(path, k), v)
+			if err != nil {
+				return nil, err
+			}
+			x[k] = nv
+		}
+		return r.applyRules(path, x), nil
+
+	case []any:
+		for i, v := range x {
+			nv, err := r.resolveNode(concat(path, strconv.Itoa(i)), v)
+			if err != nil {
+				return nil, err
+			}
+			x[i] = nv
+		}
+		return r.applyRules(path, x), nil
+
+	default:
+		return r.applyRules(path, node), nil
+	}
+}
+
+// includeRef recognizes a node shaped as exactly {"$include": ref}
+// or {"$ref": ref}.
+func includeRef(x map[string]any) (string, bool) {
+	if len(x) != 1 {
+		return "", false
+	}
+	if v, ok := x["$include"].(string); ok {
+		return v, true
+	}
+	if v, ok := x["$ref"].(string); ok {
+		return v, true
+	}
+	return "", false
+}
+
+func (r *resolver) resolveRef(path []string, ref string) (any, error) {
+	if r.resolving[ref] {
+		return nil, fmt.Errorf("%w: %s", ErrCycle, ref)
+	}
+	r.resolving[ref] = true
+	defer delete(r.resolving, ref)
+
+	v, err := r.load(ref)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s (%s): %w", PathString(path), ref, err)
+	}
+
+	return r.resolveNode(path, v)
+}
+
+func (r *resolver) load(ref string) (any, error) {
+	file, frag, hasFrag := strings.Cut(ref, "#")
+
+	switch {
+	// in-document fragment: "#/foo/bar"
+	case hasFrag && file == "":
+		return lookupPointer(r.root, frag)
+
+	// a file, optionally followed by a JSON-Pointer fragment: either
+	// it has a "/" in it, or a fragment, or its extension is one we
+	// know how to parse (so a bare "sibling.json" isn't mistaken for
+	// a dotted in-DB reference)
+	case hasFrag || strings.Contains(file, "/") || IsRegisteredFormat(path.Ext(file)):
+		fn := path.Clean(file)
+		if r.dir != "" && !path.IsAbs(fn) {
+			fn = path.Join(r.dir, fn)
+		}
+
+		v, err := ReadFileFS(r.fsys, fn)
+		if err != nil {
+			return nil, err
+		}
+		if hasFrag {
+			return lookupPointer(v, frag)
+		}
+		return v, nil
+
+	// absolute in-DB reference, dotted ("foo.bar.baz")
+	default:
+		return Get[any](r.root, strings.Split(file, "."))
+	}
+}
+
+func (r *resolver) applyRules(path []string, node any) any {
+	for _, rule := range r.rules {
+		node = rule(path, node)
+	}
+	return node
+}
+
+// lookupPointer resolves a (possibly empty) RFC 6901 JSON Pointer
+// fragment ("/foo/bar", or "" for the root) against root.
+func lookupPointer(root any, frag string) (any, error) {
+	frag = strings.TrimPrefix(frag, "/")
+	if frag == "" {
+		return root, nil
+	}
+
+	cur := root
+	for _, tok := range strings.Split(frag, "/") {
+		tok = strings.ReplaceAll(tok, "~1", "/")
+		tok = strings.ReplaceAll(tok, "~0", "~")
+
+		switch x := cur.(type) {
+		case map[string]any:
+			v, ok := x[tok]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrBadPath, frag)
+			}
+			cur = v
+		case []any:
+			i, err := strconv.Atoi(tok)
+			if err != nil || i < 0 || i >= len(x) {
+				return nil, fmt.Errorf("%w: %s", ErrBadPath, frag)
+			}
+			cur = x[i]
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrBadPath, frag)
+		}
+	}
+	return cur, nil
+}
+
+func concat(path []string, x string) []string {
+	np := make([]string, len(path)+1)
+	copy(np, path)
+	np[len(path)] = x
+	return np
+}
+
+// ReadOpt configures [Read] / [ReadFS].
+type ReadOpt func(*readConfig)
+
+type readConfig struct {
+	resolve     bool
+	resolveOpts []ResolveOpt
+	mergeFlags  SetFlags
+	extMap      ExtMap
+}
+
+// WithResolve runs [Resolve] as a final step of [Read] / [ReadFS],
+// against the same filesystem db was loaded from unless overridden
+// via [WithFS] in opts.
+func WithResolve(opts ...ResolveOpt) ReadOpt {
+	return func(c *readConfig) {
+		c.resolve = true
+		c.resolveOpts = opts
+	}
+}
+
+// WithMergeFlags sets the [SetFlags] [Read] / [ReadFS] use to
+// combine overlays (sibling files, "foo/" vs "foo.json") into db.
+// Defaults to [MergeMaps], i.e. a shallow merge.
+func WithMergeFlags(flags SetFlags) ReadOpt {
+	return func(c *readConfig) { c.mergeFlags = flags }
+}
+
+// WithExtMap makes [Read] / [ReadFS] record, into em, the extension
+// every leaf it stores was read with (e.g. "foo.bar" -> ".yaml").
+// Passing the same em to [UseExtMap] on the [Write] side makes it
+// write each leaf back out under its original format instead of
+// always defaulting to [Ext].
+func WithExtMap(em ExtMap) ReadOpt {
+	return func(c *readConfig) { c.extMap = em }
+}
@@ -0,0 +1,197 @@
+package xjson
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/mbivert/ftests"
+)
+
+// ResolveT wraps Resolve to ease tests.
+func ResolveT(db map[string]any, opts ...ResolveOpt) (map[string]any, error) {
+	err := Resolve(db, opts...)
+	return db, err
+}
+
+func TestResolveInDB(t *testing.T) {
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "absolute_in-DB_reference",
+			Fun:  ResolveT,
+			Args: []any{
+				map[string]any{
+					"foo": map[string]any{
+						"bar": "baz",
+					},
+					"alias": map[string]any{
+						"$include": "foo.bar",
+					},
+				},
+			},
+			Expected: []any{
+				map[string]any{
+					"foo": map[string]any{
+						"bar": "baz",
+					},
+					"alias": "baz",
+				},
+				nil,
+			},
+		},
+	})
+}
+
+func TestResolveFile(t *testing.T) {
+	mfs := NewMemFS(map[string]string{
+		"sibling.json": `{"hello":"world"}`,
+	})
+
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "relative_file_reference",
+			Fun:  ResolveT,
+			Args: []any{
+				map[string]any{
+					"foo": map[string]any{
+						"$include": "./sibling.json",
+					},
+				},
+				WithFS(mfs),
+			},
+			Expected: []any{
+				map[string]any{
+					"foo": map[string]any{"hello": "world"},
+				},
+				nil,
+			},
+		},
+	})
+}
+
+func TestResolveFragment(t *testing.T) {
+	mfs := NewMemFS(map[string]string{
+		"sibling.json": `{"a":{"b":"c"}}`,
+	})
+
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "file_reference_with_JSON-Pointer_fragment",
+			Fun:  ResolveT,
+			Args: []any{
+				map[string]any{
+					"foo": map[string]any{
+						"$ref": "sibling.json#/a/b",
+					},
+				},
+				WithFS(mfs),
+			},
+			Expected: []any{
+				map[string]any{"foo": "c"},
+				nil,
+			},
+		},
+	})
+}
+
+func TestResolveBareFilename(t *testing.T) {
+	mfs := NewMemFS(map[string]string{
+		"sibling.json": `{"hello":"world"}`,
+	})
+
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "bare_filename,_no_slash_no_fragment",
+			Fun:  ResolveT,
+			Args: []any{
+				map[string]any{
+					"foo": map[string]any{
+						"$include": "sibling.json",
+					},
+				},
+				WithFS(mfs),
+			},
+			Expected: []any{
+				map[string]any{
+					"foo": map[string]any{"hello": "world"},
+				},
+				nil,
+			},
+		},
+	})
+}
+
+// ReadFST wraps ReadFS to ease tests.
+func ReadFST(fsys fs.FS, path string, opts ...ReadOpt) (map[string]any, error) {
+	return ReadFS(fsys, path, opts...)
+}
+
+// A relative include in a file loaded from a subdirectory resolves
+// against that subdirectory, not the root of fsys: this is what
+// [WithResolve] wires up via [WithBaseDir].
+func TestResolveViaReadBaseDir(t *testing.T) {
+	mfs := NewMemFS(map[string]string{
+		"db/main.json":    `{"$include":"./sibling.json"}`,
+		"db/sibling.json": `"hello"`,
+	})
+
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "mem_db",
+			Fun:  ReadFST,
+			Args: []any{mfs, "db", WithResolve()},
+			Expected: []any{
+				map[string]any{"main": "hello", "sibling": "hello"},
+				nil,
+			},
+		},
+	})
+}
+
+// ResolveErrIsT wraps Resolve to ease tests on its error, since the
+// actual %w-wrapped error text embeds a nondeterministic ref (map
+// iteration order) and can't be compared verbatim.
+func ResolveErrIsT(db map[string]any, target error) bool {
+	return errors.Is(Resolve(db), target)
+}
+
+func TestResolveCycle(t *testing.T) {
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "mutual_reference",
+			Fun:  ResolveErrIsT,
+			Args: []any{
+				map[string]any{
+					"a": map[string]any{"$ref": "b"},
+					"b": map[string]any{"$ref": "a"},
+				},
+				ErrCycle,
+			},
+			Expected: []any{true},
+		},
+	})
+}
+
+func TestResolveRules(t *testing.T) {
+	exclaim := func(path []string, node any) any {
+		if s, ok := node.(string); ok {
+			return s + "!"
+		}
+		return node
+	}
+
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "exclaim_rule",
+			Fun:  ResolveT,
+			Args: []any{
+				map[string]any{"foo": "bar"},
+				WithRules(exclaim),
+			},
+			Expected: []any{
+				map[string]any{"foo": "bar!"},
+				nil,
+			},
+		},
+	})
+}
@@ -0,0 +1,38 @@
+// Package toml is a [xjson.RegisterFormat] adapter: importing it
+// (for its side effect) teaches [xjson.Read]/[xjson.Write] to
+// handle ".toml" files, pulling in github.com/BurntSushi/toml only
+// for callers that actually need it.
+package toml
+
+import (
+	"bytes"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/mbivert/xjson"
+)
+
+func decode(bs []byte) (any, error) {
+	var v map[string]any
+	if err := toml.Unmarshal(bs, &v); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+func encode(v any) ([]byte, error) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, xjson.ErrBadType
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(m); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func init() {
+	xjson.RegisterFormat(".toml", decode, encode)
+}
@@ -0,0 +1,49 @@
+package toml
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/mbivert/ftests"
+	"github.com/mbivert/xjson"
+)
+
+// ReadWriteTOMLT wraps ReadFS/WriteFS to ease tests: it reads db,
+// writes it back out with a .toml extension, and reports whether the
+// round-tripped file exists.
+func ReadWriteTOMLT(mfs fs.FS, path string) (map[string]any, bool, error) {
+	got, err := xjson.ReadFS(mfs, path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	em := xjson.ExtMap{"foo": ".toml"}
+	out := xjson.NewMemFS(nil)
+	if err := xjson.WriteFS(out, path, got, xjson.UseExtMap(em), xjson.MaxDepth(1)); err != nil {
+		return nil, false, err
+	}
+
+	_, statErr := fs.Stat(out, path+"/foo.toml")
+	return got, statErr == nil, nil
+}
+
+func TestReadWriteTOML(t *testing.T) {
+	mfs := xjson.NewMemFS(map[string]string{
+		"db/foo.toml": "bar = \"baz\"\n",
+	})
+
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "mem_db",
+			Fun:  ReadWriteTOMLT,
+			Args: []any{mfs, "db"},
+			Expected: []any{
+				map[string]any{
+					"foo": map[string]any{"bar": "baz"},
+				},
+				true,
+				nil,
+			},
+		},
+	})
+}
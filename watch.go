@@ -0,0 +1,298 @@
+package xjson
+
+// This file implements [Watch], a live-reload layer on top of
+// [Read]: a [Watcher] keeps a map[string]any in sync with a
+// JSON-directory on disk, using fsnotify to react to create/write/
+// remove/rename events and emitting a typed [Event] per affected
+// path so a long-running daemon can react to configuration changes
+// without restarting.
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// EventKind categorizes an [Event] emitted by a [Watcher].
+type EventKind int
+
+const (
+	Added EventKind = iota
+	Changed
+	Removed
+)
+
+// Event is emitted on [Watcher.Events] whenever a file backing a
+// path in the watched db is created, modified or removed; Path is
+// the same dotted-path shape [Get]/[Set] take. Only the directory
+// and its subdirectories are watched: a root file beside it (e.g.
+// "db.json") is read once by [Watch], and its later changes are not
+// observed.
+type Event struct {
+	Kind EventKind
+	Path []string
+}
+
+type watchConfig struct {
+	delay time.Duration
+}
+
+// WatchOpt configures [Watch].
+type WatchOpt func(*watchConfig)
+
+// Debounce sets how long [Watch] waits after the last filesystem
+// event in a burst before reloading the affected files. Defaults to
+// 100ms, as e.g. gosuv's fswatch does.
+func Debounce(d time.Duration) WatchOpt {
+	return func(c *watchConfig) { c.delay = d }
+}
+
+// Watcher keeps a map[string]any in sync with a JSON-directory on
+// disk; build one with [Watch].
+type Watcher struct {
+	mu  sync.RWMutex
+	db  map[string]any
+	ind string
+
+	fsw   *fsnotify.Watcher
+	delay time.Duration
+
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Watch reads path via [Read], then starts watching it: any
+// create/write/remove/rename event under the tree is, after
+// debouncing, reflected in the in-memory db and reported on
+// [Watcher.Events]. Call [Watcher.Close] once done with it.
+func Watch(path string, opts ...WatchOpt) (*Watcher, error) {
+	c := watchConfig{delay: 100 * time.Millisecond}
+	for _, o := range opts {
+		o(&c)
+	}
+
+	ind, _ := GetPaths(path)
+
+	db, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := addTreeFS(fsw, ind); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+
+	w := &Watcher{
+		db:     db,
+		ind:    ind,
+		fsw:    fsw,
+		delay:  c.delay,
+		events: make(chan Event),
+		done:   make(chan struct{}),
+	}
+
+	go w.loop()
+
+	return w, nil
+}
+
+// addTreeFS recursively adds ind and every subdirectory under it to
+// fsw: fsnotify only watches a single directory level at a time.
+func addTreeFS(fsw *fsnotify.Watcher, ind string) error {
+	return filepath.WalkDir(ind, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return fsw.Add(p)
+		}
+		return nil
+	})
+}
+
+func (w *Watcher) loop() {
+	defer close(w.events)
+
+	pending := make(map[string]bool)
+	var timer *time.Timer
+	var timerC <-chan time.Time
+
+	for {
+		select {
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+
+			if ev.Has(fsnotify.Create) {
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+					w.fsw.Add(ev.Name)
+				}
+			}
+
+			pending[ev.Name] = true
+			if timer == nil {
+				timer = time.NewTimer(w.delay)
+			} else {
+				timer.Reset(w.delay)
+			}
+			timerC = timer.C
+
+		case <-timerC:
+			w.flush(pending)
+			pending = make(map[string]bool)
+			timerC = nil
+
+		case _, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			// TODO: surface watcher errors to callers
+
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// a pending event discovered under lock by flush, emitted once the
+// lock is released: emit blocks sending on the unbuffered events
+// channel, and holding mu across that send would let a concurrent
+// Snapshot (RLock) deadlock against an undrained channel.
+type pendingEvent struct {
+	kind EventKind
+	path []string
+}
+
+func (w *Watcher) flush(pending map[string]bool) {
+	var events []pendingEvent
+
+	w.mu.Lock()
+	for fn := range pending {
+		fi, err := os.Stat(fn)
+		if err != nil {
+			if os.IsNotExist(err) {
+				xs := w.keyPath(fn)
+				Delete(w.db, xs)
+				events = append(events, pendingEvent{Removed, xs})
+			}
+			continue
+		}
+
+		if fi.IsDir() {
+			continue
+		}
+
+		xs := w.keyPath(fn)
+		added := !w.exists(xs)
+
+		// drop the stale subtree first: ReadAndStoreFile only
+		// merges keys in, so a key removed from fn since the last
+		// load would otherwise linger
+		if !added {
+			w.clear(xs)
+		}
+
+		if err := ReadAndStoreFile(w.ind, fn, w.db); err != nil {
+			continue
+		}
+
+		if added {
+			events = append(events, pendingEvent{Added, xs})
+		} else {
+			events = append(events, pendingEvent{Changed, xs})
+		}
+	}
+	w.mu.Unlock()
+
+	for _, ev := range events {
+		w.emit(ev.kind, ev.path)
+	}
+}
+
+// clear drops the subtree at xs from w.db, or the whole db if xs is
+// empty (the root file itself changed).
+func (w *Watcher) clear(xs []string) {
+	if len(xs) == 0 {
+		for k := range w.db {
+			delete(w.db, k)
+		}
+		return
+	}
+	Delete(w.db, xs)
+}
+
+// keyPath mirrors the path derivation [Store] does for fn, without
+// the error return: an fn outside ind can't reach here, it's always
+// produced by fsnotify watching ind itself.
+func (w *Watcher) keyPath(fn string) []string {
+	if isRoot(w.ind, fn) {
+		return nil
+	}
+
+	rel, err := filepath.Rel(w.ind, fn)
+	if err != nil {
+		return nil
+	}
+
+	return splitPath(strings.TrimSuffix(rel, filepath.Ext(fn)))
+}
+
+func (w *Watcher) exists(xs []string) bool {
+	if len(xs) == 0 {
+		return true
+	}
+	_, err := Get[any](w.db, xs)
+	return err == nil
+}
+
+func (w *Watcher) emit(kind EventKind, path []string) {
+	select {
+	case w.events <- Event{Kind: kind, Path: path}:
+	case <-w.done:
+	}
+}
+
+// Events returns the channel [Watch] reports changes on; it's
+// closed once the watcher is closed.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Snapshot returns a deep copy of the watcher's current db, safe to
+// read and mutate independently of concurrent reloads.
+func (w *Watcher) Snapshot() map[string]any {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	// db holds whatever the registered decoders produced (see
+	// [Decoder]), merged/deleted in place. The copy is a round trip
+	// through encoding/json: numbers come back as float64, and a
+	// value json.Marshal rejects leaves the copy nil.
+	bs, _ := json.Marshal(w.db)
+	var cp map[string]any
+	_ = json.Unmarshal(bs, &cp)
+	return cp
+}
+
+// Close stops the watcher and releases the underlying fsnotify
+// resources.
+func (w *Watcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	return w.fsw.Close()
+}
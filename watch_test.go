@@ -0,0 +1,206 @@
+package xjson
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mbivert/ftests"
+)
+
+// waitEvent waits for an event off ch, turning a timeout into an
+// error rather than blocking forever: a Watcher that stops emitting
+// would otherwise hang these tests indefinitely.
+func waitEvent(ch <-chan Event) (Event, error) {
+	select {
+	case ev := <-ch:
+		return ev, nil
+	case <-time.After(2 * time.Second):
+		return Event{}, fmt.Errorf("timed out waiting for an event")
+	}
+}
+
+// WatchLifecycleT wraps a full Watch lifecycle (initial load, then a
+// Changed, an Added and a Removed event) to ease tests: it returns a
+// map of the observables a test cares about at each step, so the
+// whole scenario becomes one comparable return value.
+func WatchLifecycleT(dbDir string) (map[string]any, error) {
+	if err := os.WriteFile(filepath.Join(dbDir, "foo.json"), []byte(`"bar"`), 0640); err != nil {
+		return nil, err
+	}
+
+	w, err := Watch(dbDir, Debounce(10*time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+
+	out := map[string]any{}
+	out["initial"] = w.Snapshot()["foo"]
+
+	if err := os.WriteFile(filepath.Join(dbDir, "foo.json"), []byte(`"baz"`), 0640); err != nil {
+		return nil, err
+	}
+	ev, err := waitEvent(w.Events())
+	if err != nil {
+		return nil, err
+	}
+	out["changedKind"] = ev.Kind
+	out["afterChanged"] = w.Snapshot()["foo"]
+
+	if err := os.WriteFile(filepath.Join(dbDir, "qux.json"), []byte(`"quux"`), 0640); err != nil {
+		return nil, err
+	}
+	ev, err = waitEvent(w.Events())
+	if err != nil {
+		return nil, err
+	}
+	out["addedKind"] = ev.Kind
+	out["afterAdded"] = w.Snapshot()["qux"]
+
+	if err := os.Remove(filepath.Join(dbDir, "qux.json")); err != nil {
+		return nil, err
+	}
+	ev, err = waitEvent(w.Events())
+	if err != nil {
+		return nil, err
+	}
+	out["removedKind"] = ev.Kind
+	_, stillPresent := w.Snapshot()["qux"]
+	out["quxStillPresent"] = stillPresent
+
+	return out, nil
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "db")
+	if err := os.MkdirAll(db, 0750); err != nil {
+		t.Fatalf("MkdirAll: %s", err.Error())
+	}
+
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "add_change_remove",
+			Fun:  WatchLifecycleT,
+			Args: []any{db},
+			Expected: []any{
+				map[string]any{
+					"initial":         "bar",
+					"changedKind":     Changed,
+					"afterChanged":    "baz",
+					"addedKind":       Added,
+					"afterAdded":      "quux",
+					"removedKind":     Removed,
+					"quxStillPresent": false,
+				},
+				nil,
+			},
+		},
+	})
+}
+
+// WatchSnapshotNoDeadlockT wraps the Snapshot-vs-undrained-event race
+// to ease tests: it triggers a flush, never drains w.Events(), and
+// reports whether Snapshot still returns promptly.
+func WatchSnapshotNoDeadlockT(dbDir string) (any, error) {
+	if err := os.WriteFile(filepath.Join(dbDir, "foo.json"), []byte(`"bar"`), 0640); err != nil {
+		return nil, err
+	}
+
+	w, err := Watch(dbDir, Debounce(10*time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+
+	// trigger a flush, but never read from w.Events(): it should
+	// still be safe to call Snapshot while that event is pending
+	if err := os.WriteFile(filepath.Join(dbDir, "foo.json"), []byte(`"baz"`), 0640); err != nil {
+		return nil, err
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan map[string]any, 1)
+	go func() { done <- w.Snapshot() }()
+
+	select {
+	case got := <-done:
+		return got["foo"], nil
+	case <-time.After(2 * time.Second):
+		return nil, fmt.Errorf("Snapshot deadlocked against an undrained event")
+	}
+}
+
+func TestWatchSnapshotNoDeadlock(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "db")
+	if err := os.MkdirAll(db, 0750); err != nil {
+		t.Fatalf("MkdirAll: %s", err.Error())
+	}
+
+	ftests.Run(t, []ftests.Test{
+		{
+			Name:     "snapshot_while_event_pending",
+			Fun:      WatchSnapshotNoDeadlockT,
+			Args:     []any{db},
+			Expected: []any{any("baz"), nil},
+		},
+	})
+}
+
+// WatchChangedDropsStaleKeysT wraps a Changed reload that drops a key
+// to ease tests: it reports whether the dropped key is still present
+// afterwards (it shouldn't be).
+func WatchChangedDropsStaleKeysT(dbDir string) (bool, error) {
+	if err := os.WriteFile(filepath.Join(dbDir, "foo.json"), []byte(`{"a":1,"b":2}`), 0640); err != nil {
+		return false, err
+	}
+
+	w, err := Watch(dbDir, Debounce(10*time.Millisecond))
+	if err != nil {
+		return false, err
+	}
+	defer w.Close()
+
+	if w.Snapshot()["foo"] == nil {
+		return false, fmt.Errorf("expected foo to be loaded")
+	}
+
+	if err := os.WriteFile(filepath.Join(dbDir, "foo.json"), []byte(`{"a":1}`), 0640); err != nil {
+		return false, err
+	}
+	ev, err := waitEvent(w.Events())
+	if err != nil {
+		return false, err
+	}
+	if ev.Kind != Changed {
+		return false, fmt.Errorf("got: %#v, expected Changed", ev)
+	}
+
+	foo, ok := w.Snapshot()["foo"].(map[string]any)
+	if !ok {
+		return false, fmt.Errorf("expected foo to still be a map")
+	}
+	_, stillPresent := foo["b"]
+	return stillPresent, nil
+}
+
+func TestWatchChangedDropsStaleKeys(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "db")
+	if err := os.MkdirAll(db, 0750); err != nil {
+		t.Fatalf("MkdirAll: %s", err.Error())
+	}
+
+	ftests.Run(t, []ftests.Test{
+		{
+			Name:     "dropped_key_not_left_stale",
+			Fun:      WatchChangedDropsStaleKeysT,
+			Args:     []any{db},
+			Expected: []any{false, nil},
+		},
+	})
+}
@@ -0,0 +1,147 @@
+package xjson
+
+// This file implements the split/indent/diff policy used by
+// [WriteFS] when it turns a map[string]any back into a
+// JSON-directory layout: the inverse of what [Read] describes at
+// the top of xjson.go.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+type writeConfig struct {
+	maxDepth      int
+	splitOnlyMaps bool
+	indent        string
+	diffOnly      bool
+	extMap        ExtMap
+}
+
+// WriteOpt configures [Write] / [WriteFS].
+type WriteOpt func(*writeConfig)
+
+// MaxDepth caps how deep [WriteFS] nests directories: the starting
+// path's direct children are at depth 1, and at depth n, a subtree
+// that would otherwise become a directory is instead collapsed into
+// a single "<key>Ext" file holding the whole subtree. MaxDepth(1)
+// thus creates no directory below the starting path.
+func MaxDepth(n int) WriteOpt {
+	return func(c *writeConfig) { c.maxDepth = n }
+}
+
+// SplitOnlyMaps keeps scalars and arrays living alongside maps in a
+// given directory out of that directory: instead of one file per
+// key, they're gathered into a single aggregated "<dir>Ext" sibling
+// file, which [DoReadAndStoreFS] reads back alongside "path/" (the
+// latter taking precedence on conflicting keys).
+func SplitOnlyMaps() WriteOpt {
+	return func(c *writeConfig) { c.splitOnlyMaps = true }
+}
+
+// Indent marshals every written [Ext] file with json.MarshalIndent
+// using prefix "" and the given indent string, instead of the
+// compact encoding [WriteFileFS] uses by default. Files written
+// under another extension (see [UseExtMap]) go through that
+// format's encoder unchanged.
+func Indent(indent string) WriteOpt {
+	return func(c *writeConfig) { c.indent = indent }
+}
+
+// DiffOnly skips rewriting a file whose marshaled bytes are
+// identical to what's already on disk, so a [Write] over a
+// version-controlled JSON dir doesn't touch mtimes/diffs of
+// unchanged files.
+func DiffOnly() WriteOpt {
+	return func(c *writeConfig) { c.diffOnly = true }
+}
+
+// UseExtMap makes [WriteFS] write each leaf out under the
+// extension em records for its dotted path (see the read-side
+// [WithExtMap]), instead of always defaulting to [Ext]: a db read
+// from "config.yaml" is written back out as YAML.
+func UseExtMap(em ExtMap) WriteOpt {
+	return func(c *writeConfig) { c.extMap = em }
+}
+
+func writeDir(wfs WritableFS, path string, db map[string]any, c *writeConfig, depth int, keyPath []string) error {
+	if c.maxDepth > 0 && depth >= c.maxDepth {
+		return writeLeaf(wfs, path+extFor(keyPath, c.extMap), db, c)
+	}
+
+	aggregate := make(map[string]any)
+
+	for k, v := range db {
+		fn := path + "/" + k
+		kp := concat(keyPath, k)
+
+		if m, ok := v.(map[string]any); ok {
+			// collapse now, before creating fn as a directory, so a
+			// subtree MaxDepth collapses doesn't leave behind an
+			// empty fn/ alongside fn.json
+			if c.maxDepth > 0 && depth+1 >= c.maxDepth {
+				if err := writeLeaf(wfs, fn+extFor(kp, c.extMap), m, c); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := wfs.MkdirAll(fn, 0750); err != nil {
+				return fmt.Errorf("creating %s: %w", fn, err)
+			}
+			if err := writeDir(wfs, fn, m, c, depth+1, kp); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if c.splitOnlyMaps {
+			aggregate[k] = v
+			continue
+		}
+
+		if err := writeLeaf(wfs, fn+extFor(kp, c.extMap), v, c); err != nil {
+			return err
+		}
+	}
+
+	if c.splitOnlyMaps && len(aggregate) > 0 {
+		if err := writeLeaf(wfs, path+extFor(keyPath, c.extMap), aggregate, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeLeaf(wfs WritableFS, fn string, v any, c *writeConfig) error {
+	var xs []byte
+	var err error
+
+	ext := filepath.Ext(fn)
+
+	switch {
+	case c.indent != "" && ext == Ext:
+		xs, err = json.MarshalIndent(v, "", c.indent)
+	default:
+		encode, ok := encoderFor(ext)
+		if !ok {
+			encode = encodeJSON
+		}
+		xs, err = encode(v)
+	}
+	if err != nil {
+		return fmt.Errorf("marshaling %s: %w", fn, err)
+	}
+
+	if c.diffOnly {
+		if cur, err := fs.ReadFile(wfs, fn); err == nil && bytes.Equal(cur, xs) {
+			return nil
+		}
+	}
+
+	return doWriteFileFS(wfs, fn, xs)
+}
@@ -0,0 +1,245 @@
+package xjson
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/mbivert/ftests"
+)
+
+// WriteMaxDepthT wraps WriteFS/ReadFileFS to ease tests: it writes db
+// under MaxDepth, reads the collapsed leaf back, and reports whether
+// the would-be directory was (wrongly) created alongside it.
+func WriteMaxDepthT(wfs WritableFS, path string, db map[string]any, depth int) (any, bool, error) {
+	if err := WriteFS(wfs, path, db, MaxDepth(depth)); err != nil {
+		return nil, false, err
+	}
+
+	got, err := ReadFileFS(wfs, path+"/foo.json")
+	if err != nil {
+		return nil, false, err
+	}
+
+	_, statErr := fs.Stat(wfs, path+"/foo")
+	return got, statErr == nil, nil
+}
+
+func TestWriteMaxDepth(t *testing.T) {
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "collapse_past_MaxDepth",
+			Fun:  WriteMaxDepthT,
+			Args: []any{
+				NewMemFS(nil), "db",
+				map[string]any{
+					"foo": map[string]any{
+						"bar": map[string]any{
+							"baz": "qux",
+						},
+					},
+				},
+				1,
+			},
+			Expected: []any{
+				map[string]any{
+					"bar": map[string]any{
+						"baz": "qux",
+					},
+				},
+				false,
+				nil,
+			},
+		},
+	})
+}
+
+// WriteSplitOnlyMapsT wraps WriteFS/ReadFS to ease tests: it writes
+// db, reads it back, and reports whether a leaf got split into its
+// own file despite SplitOnlyMaps.
+func WriteSplitOnlyMapsT(wfs WritableFS, path string, db map[string]any) (map[string]any, bool, bool, error) {
+	if err := WriteFS(wfs, path, db, SplitOnlyMaps()); err != nil {
+		return nil, false, false, err
+	}
+
+	got, err := ReadFS(wfs, path)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	_, leafErr := fs.Stat(wfs, path+"/foo.json")
+	_, rootErr := fs.Stat(wfs, path+".json")
+	return got, leafErr == nil, rootErr == nil, nil
+}
+
+func TestWriteSplitOnlyMaps(t *testing.T) {
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "non-map_leaf_stays_in_root_file",
+			Fun:  WriteSplitOnlyMapsT,
+			Args: []any{
+				NewMemFS(nil), "db",
+				map[string]any{
+					"foo": "bar",
+					"baz": map[string]any{
+						"qux": "quux",
+					},
+				},
+			},
+			Expected: []any{
+				map[string]any{
+					"foo": "bar",
+					"baz": map[string]any{
+						"qux": "quux",
+					},
+				},
+				false,
+				true,
+				nil,
+			},
+		},
+	})
+}
+
+// WriteFSRawT wraps WriteFS/fs.ReadFile to ease tests: it writes db,
+// then reads one file back raw, for exact-bytes assertions (e.g.
+// Indent).
+func WriteFSRawT(wfs WritableFS, path string, db map[string]any, filePath string, opts ...WriteOpt) (string, error) {
+	if err := WriteFS(wfs, path, db, opts...); err != nil {
+		return "", err
+	}
+
+	bs, err := fs.ReadFile(wfs, filePath)
+	return string(bs), err
+}
+
+func TestWriteIndent(t *testing.T) {
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "indented_leaf",
+			Fun:  WriteFSRawT,
+			Args: []any{
+				NewMemFS(nil), "db",
+				map[string]any{"foo": []any{"bar"}},
+				"db/foo.json",
+				Indent("\t"),
+			},
+			Expected: []any{"[\n\t\"bar\"\n]", nil},
+		},
+	})
+}
+
+// WriteFSStatSizeT wraps WriteFS/Stat to ease tests: it writes db,
+// then reports the size of one resulting file. The size alone does
+// not tell whether the file was rewritten with identical bytes.
+func WriteFSStatSizeT(wfs WritableFS, path string, db map[string]any, filePath string, opts ...WriteOpt) (int64, error) {
+	if err := WriteFS(wfs, path, db, opts...); err != nil {
+		return 0, err
+	}
+
+	st, err := fs.Stat(wfs, filePath)
+	if err != nil {
+		return 0, err
+	}
+	return st.Size(), nil
+}
+
+func TestWriteDiffOnly(t *testing.T) {
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "unchanged_leaf_keeps_its_size",
+			Fun:  WriteFSStatSizeT,
+			Args: []any{
+				NewMemFS(map[string]string{
+					"db/foo.json": `"bar"`,
+				}),
+				"db",
+				map[string]any{"foo": "bar"},
+				"db/foo.json",
+				DiffOnly(),
+			},
+			Expected: []any{int64(len(`"bar"`)), nil},
+		},
+	})
+}
+
+// ExtMapRoundTripT wraps ReadFS/WriteFS to ease tests: it reads db
+// populating em, writes it back out to a fresh fs using em, and
+// reports em alongside whether the round-tripped files exist.
+func ExtMapRoundTripT(mfs fs.FS, path string, checkPaths []string) (ExtMap, []bool, error) {
+	em := ExtMap{}
+	db, err := ReadFS(mfs, path, WithExtMap(em))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := NewMemFS(nil)
+	if err := WriteFS(out, path, db, UseExtMap(em)); err != nil {
+		return nil, nil, err
+	}
+
+	exist := make([]bool, len(checkPaths))
+	for i, p := range checkPaths {
+		_, statErr := fs.Stat(out, p)
+		exist[i] = statErr == nil
+	}
+	return em, exist, nil
+}
+
+func TestExtMapRoundTrip(t *testing.T) {
+	RegisterFormat(".fmt",
+		func(bs []byte) (any, error) { return string(bs), nil },
+		func(v any) ([]byte, error) { return []byte(v.(string)), nil },
+	)
+	defer delete(formats, ".fmt")
+
+	mfs := NewMemFS(map[string]string{
+		"db/foo.fmt":  "bar",
+		"db/baz.json": `"qux"`,
+	})
+
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "mixed_extensions",
+			Fun:  ExtMapRoundTripT,
+			Args: []any{
+				mfs, "db",
+				[]string{"db/foo.fmt", "db/baz.json"},
+			},
+			Expected: []any{
+				ExtMap{"foo": ".fmt", "baz": ".json"},
+				[]bool{true, true},
+				nil,
+			},
+		},
+	})
+}
+
+// A root file ("db.fmt", holding the whole top-level map) must
+// also populate em for each of its keys, same as a regular leaf.
+func TestExtMapRoundTripRoot(t *testing.T) {
+	RegisterFormat(".fmt",
+		func(bs []byte) (any, error) { return map[string]any{"a": "1", "b": "2"}, nil },
+		func(v any) ([]byte, error) { return []byte("fake"), nil },
+	)
+	defer delete(formats, ".fmt")
+
+	mfs := NewMemFS(map[string]string{
+		"db.fmt": "a: 1\nb: 2\n",
+	})
+
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "root_file",
+			Fun:  ExtMapRoundTripT,
+			Args: []any{
+				mfs, "db",
+				[]string{"db/a.fmt"},
+			},
+			Expected: []any{
+				ExtMap{"a": ".fmt", "b": ".fmt"},
+				[]bool{true},
+				nil,
+			},
+		},
+	})
+}
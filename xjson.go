@@ -18,12 +18,19 @@
 // a directory `input/foo/` can overload the content of a file
 // `input/foo.json`.
 //
+// [Read]/[Write] go through an [OSFS] by default, but [ReadFS]/
+// [WriteFS] accept any [fs.FS]/[WritableFS], so an embedded FS, a
+// [MemFS], or a remote backend can be plugged in instead.
+//
+// [Watch] keeps a db in sync with such a directory as it changes on
+// disk, for long-running processes that want live configuration
+// reload.
+//
 // TODO more tests, documentation, README.md, etc.
 // also, can we get rid of the special case for root?
 package xjson
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"errors"
@@ -40,8 +47,16 @@ func splitPath(path string) []string {
 	return strings.Split(path, string(os.PathSeparator))
 }
 
+// TrimExt strips fn's extension, but only if it's a registered
+// format (see [RegisterFormat]): "foo.json" becomes "foo", but
+// "foo.bar" is left untouched, since ".bar" isn't a config format
+// this package knows how to write back out.
 func TrimExt(fn string) string {
-	return strings.TrimSuffix(fn, filepath.Ext(fn))
+	ext := filepath.Ext(fn)
+	if !IsRegisteredFormat(ext) {
+		return fn
+	}
+	return strings.TrimSuffix(fn, ext)
 }
 
 func isRoot(ind, fn string) bool {
@@ -103,8 +118,6 @@ type SetFlags uint16
 const (
 	// when v is a map[string]any, and the leaf pointed to by xs is
 	// map[string]any as well, (shallow) merge the two maps.
-	//
-	// TODO: maybe we'll want a "deep" merge flag?
 	MergeMaps = 1 << iota
 
 	// when v is a []T and the leaf pointed to by xs is
@@ -115,8 +128,38 @@ const (
 	// is not a map[string]any (e.g. a string, or an array), remove
 	// this value
 	ForceThrough
+
+	// like MergeMaps, but recurses into nested map[string]any
+	// values instead of stopping at the first level. See
+	// [DeepMergePreferOld] and [DeepMergeError] for how leaf
+	// conflicts (same key, differing, non-mergeable values) are
+	// resolved; by default the incoming value wins, same as
+	// MergeMaps.
+	DeepMergeMaps
+
+	// when v is a []any and the leaf pointed to by xs is a []any
+	// as well, append v to the leaf. Unlike AppendArrays, this
+	// works against the shape json.Unmarshal actually produces
+	// for arrays loaded into an any.
+	AppendArraysAny
+
+	// de-duplicates (via reflect.DeepEqual) while appending,
+	// whether via AppendArrays or AppendArraysAny.
+	UniqueAppend
+
+	// under DeepMergeMaps, keep the pre-existing value on a leaf
+	// conflict instead of the default prefer-new behaviour.
+	DeepMergePreferOld
+
+	// under DeepMergeMaps, fail with ErrConflict on a leaf
+	// conflict instead of silently picking a side.
+	DeepMergeError
 )
 
+// ErrConflict is returned by [SetF]/[Store] under [DeepMergeError]
+// when two values being deep-merged disagree on a leaf.
+var ErrConflict = errors.New("merge conflict")
+
 // TODO: tests
 func SetF[T any](db map[string]any, xs[]string, v any, flags SetFlags) error {
 	var p map[string]any
@@ -124,7 +167,18 @@ func SetF[T any](db map[string]any, xs[]string, v any, flags SetFlags) error {
 	p = db
 	for n, x := range xs {
 		if n == len(xs)-1 {
-			if (flags & MergeMaps == MergeMaps) {
+			if (flags & DeepMergeMaps == DeepMergeMaps) {
+				w, ok1 := v.(map[string]any)
+				q, ok2 := p[x].(map[string]any)
+				if ok1 && ok2 {
+					if err := deepMergeMaps(q, w, flags); err != nil {
+						return fmt.Errorf(
+							"%s: %w", PathString(xs[:n+1]), err,
+						)
+					}
+					continue
+				}
+			} else if (flags & MergeMaps == MergeMaps) {
 				w, ok1 := v.(map[string]any)
 				q, ok2 := p[x].(map[string]any)
 				if ok1 && ok2 {
@@ -134,11 +188,18 @@ func SetF[T any](db map[string]any, xs[]string, v any, flags SetFlags) error {
 					continue
 				}
 			}
-			if (flags & AppendArrays == AppendArrays) {
+			if (flags & AppendArraysAny == AppendArraysAny) {
+				w, ok1 := v.([]any)
+				q, ok2 := p[x].([]any)
+				if ok1 && ok2 {
+					p[x] = appendUnique(q, w, flags)
+					continue
+				}
+			} else if (flags & AppendArrays == AppendArrays) {
 				w, ok1 := v.([]T)
 				q, ok2 := p[x].([]T)
 				if ok1 && ok2 {
-					p[x] = append(q, w...)
+					p[x] = appendUniqueT(q, w, flags)
 					continue
 				}
 			}
@@ -182,7 +243,37 @@ func Set(db map[string]any, xs[]string, v any) error {
 	return SetF[any](db, xs, v, MergeMaps)
 }
 
-func Store(ind, fn string, y any, db map[string]any) error {
+// Delete removes the value at xs from db, if any; it's the inverse
+// of [Set], used e.g. by [Watcher] when a file backing a path goes
+// away. A missing xs, in whole or in part, is not an error.
+func Delete(db map[string]any, xs []string) error {
+	p := db
+	for n, x := range xs {
+		if n == len(xs)-1 {
+			delete(p, x)
+			return nil
+		}
+
+		q, ok := p[x].(map[string]any)
+		if !ok {
+			return nil
+		}
+		p = q
+	}
+
+	return nil
+}
+
+func StoreF(ind, fn string, y any, db map[string]any, flags SetFlags) error {
+	return StoreExt(ind, fn, y, db, flags, nil)
+}
+
+// StoreExt is [StoreF], additionally recording, under em (if
+// non-nil), the extension fn was read with against the dotted path
+// y gets stored at. [WriteFS] can later replay this via
+// [UseExtMap] to write e.g. "config.yaml" back out as YAML instead
+// of defaulting to [Ext].
+func StoreExt(ind, fn string, y any, db map[string]any, flags SetFlags, em ExtMap) error {
 	rel, err := filepath.Rel(ind, fn)
 	if err != nil {
 		return err
@@ -194,14 +285,24 @@ func Store(ind, fn string, y any, db map[string]any) error {
 		if !ok {
 			return fmt.Errorf("root isn't a hash")
 		}
-		// merge maps
+
+		if em != nil {
+			for k := range z {
+				em[k] = filepath.Ext(fn)
+			}
+		}
+
+		if flags&DeepMergeMaps == DeepMergeMaps {
+			return deepMergeMaps(db, z, flags)
+		}
+
+		// merge maps (shallow)
 		for k, v := range z {
 			db[k] = v
 		}
 		return nil
 	}
 
-	rel = rel
 	xs := splitPath(strings.TrimSuffix(rel, filepath.Ext(fn)))
 	/*
 	xs := splitPath(
@@ -209,65 +310,87 @@ func Store(ind, fn string, y any, db map[string]any) error {
 	)
 	*/
 
-	return Set(db, xs, y)
+	if em != nil {
+		em[PathString(xs)] = filepath.Ext(fn)
+	}
+
+	return SetF[any](db, xs, y, flags)
+}
+
+// Good defaults
+func Store(ind, fn string, y any, db map[string]any) error {
+	return StoreF(ind, fn, y, db, MergeMaps)
 }
 
 // TODO: test line/column numbers in error.
-func ReadFile(fn string) (any, error) {
-	bs, err := os.ReadFile(fn)
+func ReadFileFS(fsys fs.FS, fn string) (any, error) {
+	bs, err := fs.ReadFile(fsys, fn)
 	if err != nil {
 		return nil, fmt.Errorf("reading %s: %w", fn, err)
 	}
 
-	var v any
-	err = json.Unmarshal(bs, &v)
-
-	// error − if any − is expected to be a json.SyntaxError,
-	// which contains an offset, from which we can compute line
-	// and column numbers.
-	var jErr *json.SyntaxError
-	if errors.As(err, &jErr) {
-		off := jErr.Offset
-		// https://github.com/golang/go/issues/43513#issuecomment-755754498
-		line := 1 + bytes.Count(bs[:off], []byte("\n"))
-		col := int64(1) + off - int64(bytes.LastIndex(bs[:off], []byte("\n")) + len("\n"))
+	decode, ok := decoderFor(filepath.Ext(fn))
+	if !ok {
+		decode = decodeJSON
+	}
 
-		return v, fmt.Errorf("unmarshaling %s:%d:%d %w", fn, line, col, err)
+	v, err := decode(bs)
+	if err != nil {
+		return v, fmt.Errorf("unmarshaling %s:%w", fn, err)
 	}
 
-	return v, err
+	return v, nil
+}
+
+// ReadFile slurps fn off disk and attempts to decode it with the
+// decoder registered for its extension (JSON if there is none).
+func ReadFile(fn string) (any, error) {
+	return ReadFileFS(OSFS{}, fn)
 }
 
-func doWriteFile(fn string, xs []byte) error {
+func doWriteFileFS(wfs WritableFS, fn string, xs []byte) error {
 	dir := filepath.Dir(fn)
-	if err := os.MkdirAll(dir, 0750); err != nil {
+	if err := wfs.MkdirAll(dir, 0750); err != nil {
 		return fmt.Errorf("creating %s: %w", dir, err)
 	}
 
-	if err := os.WriteFile(fn, xs, 0660); err != nil {
+	if err := wfs.WriteFile(fn, xs, 0660); err != nil {
 		return fmt.Errorf("writing to %s: %w", fn, err)
 	}
 
 	return nil
 }
 
-func WriteFile(fn string, v any) error {
-	xs, err := json.Marshal(v)
+func WriteFileFS(wfs WritableFS, fn string, v any) error {
+	encode, ok := encoderFor(filepath.Ext(fn))
+	if !ok {
+		encode = encodeJSON
+	}
+
+	xs, err := encode(v)
 	if err != nil {
-		return fmt.Errorf("marshaling: %w", err)
+		return fmt.Errorf("marshaling %s: %w", fn, err)
 	}
 
-	return doWriteFile(fn, xs)
+	return doWriteFileFS(wfs, fn, xs)
+}
+
+func WriteFile(fn string, v any) error {
+	return WriteFileFS(OSFS{}, fn, v)
 }
 
 // XXX may parametrize the indent?
-func WriteIndentFile(fn string, v any) error {
+func WriteIndentFileFS(wfs WritableFS, fn string, v any) error {
 	xs, err := json.MarshalIndent(v, "", "\t")
 	if err != nil {
 		return fmt.Errorf("marshaling: %w", err)
 	}
 
-	return doWriteFile(fn, xs)
+	return doWriteFileFS(wfs, fn, xs)
+}
+
+func WriteIndentFile(fn string, v any) error {
+	return WriteIndentFileFS(OSFS{}, fn, v)
 }
 
 func ReadFileT(t *testing.T, fn string) any {
@@ -279,39 +402,81 @@ func ReadFileT(t *testing.T, fn string) any {
 	return v
 }
 
-// ReadFile slurps the file pointed to by fn, and attempts to
-// JSON-unmarshal it to "to".
-func ReadAndStoreFile(ind, fn string, db map[string]any) error {
-	v, err := ReadFile(fn)
+// ReadAndStoreFileFSF slurps fn off fsys, decodes it as
+// [ReadFileFS] does, and stores it into db, per flags.
+func ReadAndStoreFileFSF(fsys fs.FS, ind, fn string, db map[string]any, flags SetFlags) error {
+	return ReadAndStoreFileFSFExt(fsys, ind, fn, db, flags, nil)
+}
+
+// ReadAndStoreFileFSFExt is [ReadAndStoreFileFSF], threading em
+// through to [StoreExt]; see [WithExtMap].
+func ReadAndStoreFileFSFExt(fsys fs.FS, ind, fn string, db map[string]any, flags SetFlags, em ExtMap) error {
+	v, err := ReadFileFS(fsys, fn)
 	if err != nil {
 		return err
 	}
 
-	return Store(ind, fn, v, db)
+	return StoreExt(ind, fn, v, db, flags, em)
 }
 
-func ReadAndStoreDir(ind string, db map[string]any) error {
-	err := filepath.Walk(ind, func(fn string, info fs.FileInfo, err error) error {
+// ReadAndStoreFileFS slurps fn off fsys, decodes it as
+// [ReadFileFS] does, and stores it into db.
+func ReadAndStoreFileFS(fsys fs.FS, ind, fn string, db map[string]any) error {
+	return ReadAndStoreFileFSF(fsys, ind, fn, db, MergeMaps)
+}
+
+func ReadAndStoreFileF(ind, fn string, db map[string]any, flags SetFlags) error {
+	return ReadAndStoreFileFSF(OSFS{}, ind, fn, db, flags)
+}
+
+func ReadAndStoreFile(ind, fn string, db map[string]any) error {
+	return ReadAndStoreFileFSF(OSFS{}, ind, fn, db, MergeMaps)
+}
+
+func ReadAndStoreDirFSF(fsys fs.FS, ind string, db map[string]any, flags SetFlags) error {
+	return ReadAndStoreDirFSFExt(fsys, ind, db, flags, nil)
+}
+
+// ReadAndStoreDirFSFExt is [ReadAndStoreDirFSF], threading em
+// through to [ReadAndStoreFileFSFExt]; see [WithExtMap].
+func ReadAndStoreDirFSFExt(fsys fs.FS, ind string, db map[string]any, flags SetFlags, em ExtMap) error {
+	return fs.WalkDir(fsys, ind, func(fn string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if info.IsDir() {
+		if d.IsDir() {
 			return nil
 		}
 
-		return ReadAndStoreFile(ind, fn, db)
+		// skip files whose format we don't know how to parse,
+		// so a JSON-directory can contain e.g. a README.md
+		if !IsRegisteredFormat(filepath.Ext(fn)) {
+			return nil
+		}
+
+		return ReadAndStoreFileFSFExt(fsys, ind, fn, db, flags, em)
 	})
+}
+
+func ReadAndStoreDirFS(fsys fs.FS, ind string, db map[string]any) error {
+	return ReadAndStoreDirFSF(fsys, ind, db, MergeMaps)
+}
+
+func ReadAndStoreDirF(ind string, db map[string]any, flags SetFlags) error {
+	return ReadAndStoreDirFSF(OSFS{}, ind, db, flags)
+}
 
-	return err
+func ReadAndStoreDir(ind string, db map[string]any) error {
+	return ReadAndStoreDirFSF(OSFS{}, ind, db, MergeMaps)
 }
 
 func GetPaths(path string) (dn, fn string) {
 	fn, dn = path, path
 
 	// XXX test db/
-	if strings.HasSuffix(path, Ext) {
-		dn = strings.TrimSuffix(strings.TrimRight(path, "/"), Ext)
+	if ext := filepath.Ext(path); IsRegisteredFormat(ext) {
+		dn = strings.TrimSuffix(strings.TrimRight(path, "/"), ext)
 	} else {
 		fn = path + Ext
 	}
@@ -320,36 +485,108 @@ func GetPaths(path string) (dn, fn string) {
 }
 
 // if `path` is "path/to/db", tries to read, in that order:
-//	1. path/to/db.json
+//	1. path/to/db.<ext>, for every registered ext (e.g. db.json,
+//	   db.yaml, ...)
 //	2. path/to/db/
-// both reads may succeed. values from db/ would eventually
-// supersed those from db.json.
-func DoReadAndStore(path string, db map[string]any) error {
-	dn, fn := GetPaths(path)
+// all reads may succeed. values from db/ would eventually supersed
+// those from db.<ext>, and later exts in the (sorted) registry
+// supersede earlier ones.
+func DoReadAndStoreFSF(fsys fs.FS, path string, db map[string]any, flags SetFlags) error {
+	return DoReadAndStoreFSFExt(fsys, path, db, flags, nil)
+}
 
-	// keep going if fn doesn't exist
-	err0 := ReadAndStoreFile(dn, fn, db);
-	if err0 != nil && !errors.Is(err0, os.ErrNotExist) {
-		return err0
+// DoReadAndStoreFSFExt is [DoReadAndStoreFSF], threading em through
+// to every file it stores; see [WithExtMap].
+func DoReadAndStoreFSFExt(fsys fs.FS, path string, db map[string]any, flags SetFlags, em ExtMap) error {
+	dn, _ := GetPaths(path)
+
+	var errs []error
+	found := false
+
+	for _, ext := range RegisteredExts() {
+		err := ReadAndStoreFileFSFExt(fsys, dn, dn+ext, db, flags, em)
+		switch {
+		case err == nil:
+			found = true
+		case errors.Is(err, os.ErrNotExist):
+			errs = append(errs, err)
+		default:
+			return err
+		}
 	}
 
 	// keep going if dn doesn't exist
-	err1 := ReadAndStoreDir(dn, db)
+	err1 := ReadAndStoreDirFSFExt(fsys, dn, db, flags, em)
 	if err1 != nil && !errors.Is(err1, os.ErrNotExist) {
 		return err1
 	}
 
-	// fn AND dn do not exist: we expect at least
-	// one of them to.
-	if err0 != nil && err1 != nil {
-		return errors.Join(err0, err1)
+	// neither any db.<ext> nor dn exist: we expect at least one to.
+	if !found && err1 != nil {
+		return errors.Join(append(errs, err1)...)
 	}
 
 	return nil
 }
 
-func Read(path string) (map[string]any, error) {
+func DoReadAndStoreFS(fsys fs.FS, path string, db map[string]any) error {
+	return DoReadAndStoreFSF(fsys, path, db, MergeMaps)
+}
+
+func DoReadAndStoreF(path string, db map[string]any, flags SetFlags) error {
+	return DoReadAndStoreFSF(OSFS{}, path, db, flags)
+}
+
+func DoReadAndStore(path string, db map[string]any) error {
+	return DoReadAndStoreFSF(OSFS{}, path, db, MergeMaps)
+}
+
+// ReadFS is [Read] against an arbitrary fs.FS, so callers can plug
+// in an embedded FS, an afero-backed FS, a [MemFS], a remote
+// backend, etc. without touching the loader itself. [WithMergeFlags]
+// picks the [SetFlags] used to combine overlays (sibling files,
+// "foo/" vs "foo.json") instead of the default shallow [MergeMaps].
+func ReadFS(fsys fs.FS, path string, opts ...ReadOpt) (map[string]any, error) {
 	db := make(map[string]any)
 
-	return db, DoReadAndStore(path, db)
+	c := readConfig{mergeFlags: MergeMaps}
+	for _, o := range opts {
+		o(&c)
+	}
+
+	if err := DoReadAndStoreFSFExt(fsys, path, db, c.mergeFlags, c.extMap); err != nil {
+		return db, err
+	}
+
+	if c.resolve {
+		dn, _ := GetPaths(path)
+		ropts := append([]ResolveOpt{WithFS(fsys), WithBaseDir(dn)}, c.resolveOpts...)
+		if err := Resolve(db, ropts...); err != nil {
+			return db, err
+		}
+	}
+
+	return db, nil
+}
+
+func Read(path string, opts ...ReadOpt) (map[string]any, error) {
+	return ReadFS(OSFS{}, path, opts...)
+}
+
+// WriteFS is the inverse of [ReadFS]: every nested map[string]any
+// in db becomes a directory on wfs, and every leaf becomes a
+// "<key>Ext" file under it. See [MaxDepth], [SplitOnlyMaps],
+// [Indent] and [DiffOnly] for ways to tweak this layout.
+func WriteFS(wfs WritableFS, path string, db map[string]any, opts ...WriteOpt) error {
+	var c writeConfig
+	for _, o := range opts {
+		o(&c)
+	}
+
+	return writeDir(wfs, path, db, &c, 0, nil)
+}
+
+// Write is [WriteFS] against [OSFS].
+func Write(path string, db map[string]any, opts ...WriteOpt) error {
+	return WriteFS(OSFS{}, path, db, opts...)
 }
@@ -1,6 +1,7 @@
 package xjson
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
@@ -206,6 +207,37 @@ func TestStore(t *testing.T) {
 	})
 }
 
+// StoreFT wraps StoreF to ease tests
+func StoreFT(ind, fn string, y any, db map[string]any, flags SetFlags) (map[string]any, error) {
+	err := StoreF(ind, fn, y, db, flags)
+	return db, err
+}
+
+func TestStoreF(t *testing.T) {
+	ftests.Run(t, []ftests.Test{
+		{
+			Name:    "special_case:_root,_DeepMergeMaps",
+			Fun:      StoreFT,
+			Args:     []any{
+				"path/to/db/", "path/to/db.json",
+				map[string]any{
+					"foo": map[string]any{"a": "1"},
+				},
+				map[string]any{
+					"foo": map[string]any{"b": "2"},
+				},
+				SetFlags(DeepMergeMaps),
+			},
+			Expected: []any{
+				map[string]any{
+					"foo": map[string]any{"a": "1", "b": "2"},
+				},
+				nil,
+			},
+		},
+	})
+}
+
 func TestRead(t *testing.T) {
 	ftests.Run(t, []ftests.Test{
 		{
@@ -347,9 +379,102 @@ func TestSetF(t *testing.T) {
 				nil,
 			},
 		},
+		{
+			Name:    "AppendArraysAny",
+			Fun:      SetFT[any],
+			Args:     []any{
+				map[string]any{
+					"foo" : []any{"hello"},
+				},
+				[]string{"foo"},
+				[]any{"world"},
+				SetFlags(AppendArraysAny),
+			},
+			Expected: []any{
+				map[string]any{
+					"foo": []any{"hello", "world"},
+				},
+				nil,
+			},
+		},
+		{
+			Name:    "AppendArraysAny,_UniqueAppend",
+			Fun:      SetFT[any],
+			Args:     []any{
+				map[string]any{
+					"foo" : []any{"hello", "world"},
+				},
+				[]string{"foo"},
+				[]any{"world", "!"},
+				SetFlags(AppendArraysAny | UniqueAppend),
+			},
+			Expected: []any{
+				map[string]any{
+					"foo": []any{"hello", "world", "!"},
+				},
+				nil,
+			},
+		},
+		{
+			Name:    "DeepMergeMaps",
+			Fun:      SetFT[any],
+			Args:     []any{
+				map[string]any{
+					"foo": map[string]any{
+						"bar": map[string]any{"a": "1"},
+						"baz": "kept",
+					},
+				},
+				[]string{"foo"},
+				map[string]any{
+					"bar": map[string]any{"b": "2"},
+					"qux": "new",
+				},
+				SetFlags(DeepMergeMaps),
+			},
+			Expected: []any{
+				map[string]any{
+					"foo": map[string]any{
+						"bar": map[string]any{"a": "1", "b": "2"},
+						"baz": "kept",
+						"qux": "new",
+					},
+				},
+				nil,
+			},
+		},
+		{
+			Name:    "DeepMergeMaps,_DeepMergePreferOld",
+			Fun:      SetFT[any],
+			Args:     []any{
+				map[string]any{
+					"foo": map[string]any{"a": "old"},
+				},
+				[]string{"foo"},
+				map[string]any{"a": "new"},
+				SetFlags(DeepMergeMaps | DeepMergePreferOld),
+			},
+			Expected: []any{
+				map[string]any{
+					"foo": map[string]any{"a": "old"},
+				},
+				nil,
+			},
+		},
 	})
 }
 
+func TestSetFDeepMergeError(t *testing.T) {
+	db := map[string]any{
+		"foo": map[string]any{"a": "old"},
+	}
+
+	err := SetF[any](db, []string{"foo"}, map[string]any{"a": "new"}, DeepMergeMaps|DeepMergeError)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("got: %v, expected an %s error", err, ErrConflict)
+	}
+}
+
 // SetT wraps Set to ease tests
 func SetT(db map[string]any, xs[]string, v any) (map[string]any, error) {
 	err := Set(db, xs, v)
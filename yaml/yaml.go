@@ -0,0 +1,28 @@
+// Package yaml is a [xjson.RegisterFormat] adapter: importing it
+// (for its side effect) teaches [xjson.Read]/[xjson.Write] to
+// handle ".yaml"/".yml" files, pulling in gopkg.in/yaml.v3 only for
+// callers that actually need it.
+package yaml
+
+import (
+	"gopkg.in/yaml.v3"
+
+	"github.com/mbivert/xjson"
+)
+
+func decode(bs []byte) (any, error) {
+	var v any
+	if err := yaml.Unmarshal(bs, &v); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+func encode(v any) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func init() {
+	xjson.RegisterFormat(".yaml", decode, encode)
+	xjson.RegisterFormat(".yml", decode, encode)
+}
@@ -0,0 +1,49 @@
+package yaml
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/mbivert/ftests"
+	"github.com/mbivert/xjson"
+)
+
+// ReadWriteYAMLT wraps ReadFS/WriteFS to ease tests: it reads db,
+// writes it back out with a .yaml extension, and reports whether the
+// round-tripped file exists.
+func ReadWriteYAMLT(mfs fs.FS, path string) (map[string]any, bool, error) {
+	got, err := xjson.ReadFS(mfs, path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	em := xjson.ExtMap{"foo": ".yaml"}
+	out := xjson.NewMemFS(nil)
+	if err := xjson.WriteFS(out, path, got, xjson.UseExtMap(em), xjson.MaxDepth(1)); err != nil {
+		return nil, false, err
+	}
+
+	_, statErr := fs.Stat(out, path+"/foo.yaml")
+	return got, statErr == nil, nil
+}
+
+func TestReadWriteYAML(t *testing.T) {
+	mfs := xjson.NewMemFS(map[string]string{
+		"db/foo.yaml": "bar: baz\n",
+	})
+
+	ftests.Run(t, []ftests.Test{
+		{
+			Name: "mem_db",
+			Fun:  ReadWriteYAMLT,
+			Args: []any{mfs, "db"},
+			Expected: []any{
+				map[string]any{
+					"foo": map[string]any{"bar": "baz"},
+				},
+				true,
+				nil,
+			},
+		},
+	})
+}